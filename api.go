@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
+	"log"
 	"net/http"
 	"net/url"
 	"time"
@@ -24,6 +24,81 @@ type API struct {
 	SkipSSLValidation     bool
 	Verbose               bool
 	ZoneID                string
+
+	tokenSource   oauth2.TokenSource
+	tokenNotifier func(*oauth2.Token)
+
+	// Populated by Option functions and consumed by New; unused once the API
+	// has been constructed.
+	clientID     string
+	clientSecret string
+	username     string
+	password     string
+	code         string
+	redirectURI  string
+	refreshToken string
+	token        *oauth2.Token
+	tokenFormat  TokenFormat
+	httpClient   *http.Client
+	userAgent    string
+	ctx          context.Context
+	codeVerifier string
+	onAuthURL    func(string)
+	assertion    string
+
+	// RequestHook, if set, is called with every outgoing request, regardless
+	// of Verbose. ResponseHook is the equivalent for responses. Both are
+	// useful for plugging in tracing (e.g. OpenTelemetry spans) without
+	// opting into the Verbose text dump.
+	RequestHook  func(*http.Request)
+	ResponseHook func(*http.Response)
+
+	logger *log.Logger
+}
+
+// Option configures an API during construction. Options are applied by the
+// NewWith* constructors before the authenticated client is built, so they can
+// influence how the underlying TokenSource is assembled.
+type Option func(*API)
+
+// WithTokenNotifier returns an Option that causes f to be invoked with the
+// first token the API's TokenSource returns, and again every time it returns
+// a token that differs from the one it previously returned. This is the only reliable way to observe UAA rotating
+// a refresh token out from under a long-lived oauth2.TokenSource.
+func WithTokenNotifier(f func(*oauth2.Token)) Option {
+	return func(a *API) {
+		a.tokenNotifier = f
+	}
+}
+
+func applyOptions(a *API, opts []Option) {
+	for _, opt := range opts {
+		opt(a)
+	}
+}
+
+// wrapTokenSource stores source as the API's TokenSource, wrapping it in a
+// notifyRefreshTokenSource first if WithTokenNotifier was used.
+func (a *API) wrapTokenSource(source oauth2.TokenSource) oauth2.TokenSource {
+	if a.tokenNotifier == nil {
+		a.tokenSource = source
+		return source
+	}
+	wrapped := &notifyRefreshTokenSource{base: source, notify: a.tokenNotifier}
+	a.tokenSource = wrapped
+	return wrapped
+}
+
+// Token returns the API's current access token, refreshing it via its
+// TokenSource if it has expired. It returns an error if the API does not
+// keep track of a TokenSource, e.g. one built as a struct literal rather than
+// by a constructor. An API built with WithToken (or NewWithToken) always
+// returns that same token.
+func (a *API) Token() (*oauth2.Token, error) {
+	if a.tokenSource == nil {
+		return nil, errors.New("API was not constructed with a TokenSource")
+	}
+	return a.tokenSource.Token()
 }
 
 // TokenFormat is the format of a token.
@@ -46,7 +121,7 @@ func (t TokenFormat) String() string {
 }
 
 type tokenTransport struct {
-	underlyingTransport *http.Transport
+	underlyingTransport http.RoundTripper
 	token               oauth2.Token
 }
 
@@ -55,178 +130,208 @@ func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.underlyingTransport.RoundTrip(req)
 }
 
-// NewWithToken builds an API that uses the given token to make authenticated
-// requests to the UAA API.
-func NewWithToken(target string, zoneID string, token oauth2.Token) (*API, error) {
-	if token.AccessToken == "" || token.Expiry.Before(time.Now()) {
-		return nil, errors.New("must supply a valid token")
-	}
-	u, err := BuildTargetURL(target)
-	if err != nil {
-		return nil, err
-	}
+// userAgentTransport sets the User-Agent header on every outgoing request
+// before delegating to underlying.
+type userAgentTransport struct {
+	underlying http.RoundTripper
+	userAgent  string
+}
 
-	tokenClient := &http.Client{
-		Transport: &tokenTransport{
-			underlyingTransport: &http.Transport{
-				Proxy: http.ProxyFromEnvironment,
-				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
-					KeepAlive: 30 * time.Second,
-					DualStack: true,
-				}).DialContext,
-				MaxIdleConns:          100,
-				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-			token: token,
-		},
-	}
-
-	client := &http.Client{Transport: http.DefaultTransport}
-	return &API{
-		UnauthenticatedClient: client,
-		AuthenticatedClient:   tokenClient,
-		TargetURL:             u,
-		ZoneID:                zoneID,
-	}, nil
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	underlying := t.underlying
+	if underlying == nil {
+		underlying = http.DefaultTransport
+	}
+	return underlying.RoundTrip(req)
 }
 
-// NewWithClientCredentials builds an API that uses the client credentials grant
-// to get a token for use with the UAA API.
-func NewWithClientCredentials(target string, zoneID string, clientID string, clientSecret string, tokenFormat TokenFormat) (*API, error) {
-	u, err := BuildTargetURL(target)
-	if err != nil {
-		return nil, err
+// buildUnauthenticatedClient constructs the *http.Client a constructor should
+// use to talk to UAA before any OAuth2 token is involved, applying
+// WithHTTPClient/WithUserAgent and wrapping the result so Verbose/WithLogger
+// and RequestHook/ResponseHook apply no matter which constructor was used.
+// ensureTransport runs first, while Transport is still the bare
+// *http.Transport it type-asserts against, since it would not recognize a
+// transport already wrapped by wrapLoggingTransport/userAgentTransport.
+// wrapLoggingTransport runs closer to the wire than userAgentTransport so
+// that whatever gets dumped to the Verbose/WithLogger log reflects the
+// request as actually sent, custom User-Agent included.
+func (a *API) buildUnauthenticatedClient() *http.Client {
+	client := a.httpClient
+	if client == nil {
+		client = &http.Client{Transport: http.DefaultTransport}
 	}
+	clientCopy := *client
+	a.ensureTransport(&clientCopy)
 
-	tokenURL := urlWithPath(*u, "/oauth/token")
-	v := url.Values{}
-	v.Add("token_format", tokenFormat.String())
-	c := &clientcredentials.Config{
-		ClientID:       clientID,
-		ClientSecret:   clientSecret,
-		TokenURL:       tokenURL.String(),
-		EndpointParams: v,
-	}
-	client := &http.Client{Transport: http.DefaultTransport}
-	return &API{
-		UnauthenticatedClient: client,
-		AuthenticatedClient:   c.Client(context.WithValue(context.Background(), oauth2.HTTPClient, client)),
-		TargetURL:             u,
-		ZoneID:                zoneID,
-	}, nil
+	transport := a.wrapLoggingTransport(clientCopy.Transport)
+	if a.userAgent != "" {
+		transport = &userAgentTransport{underlying: transport, userAgent: a.userAgent}
+	}
+	clientCopy.Transport = transport
+	return &clientCopy
 }
 
-// NewWithPasswordCredentials builds an API that uses the password credentials
-// grant to get a token for use with the UAA API.
-func NewWithPasswordCredentials(target string, zoneID string, clientID string, clientSecret string, username string, password string, tokenFormat TokenFormat) (*API, error) {
+// wrapLoggingTransport wraps rt so requests/responses are dumped to a's
+// logger when a.Verbose is true, and RequestHook/ResponseHook are invoked
+// regardless of Verbose.
+func (a *API) wrapLoggingTransport(rt http.RoundTripper) http.RoundTripper {
+	return &loggingTransport{api: a, underlying: rt}
+}
+
+// New builds an API against target, configured by opts. The options select
+// how the API authenticates: WithToken takes precedence, then
+// WithRefreshToken, WithAuthorizationCode and WithPasswordCredentials, and
+// WithClientCredentials on its own selects the client credentials grant. If
+// none of them is supplied, New returns an error. WithPasswordCredentials,
+// WithAuthorizationCode and
+// WithRefreshToken also require WithClientCredentials, since UAA
+// authenticates the client alongside the grant itself.
+func New(target string, opts ...Option) (*API, error) {
 	u, err := BuildTargetURL(target)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenURL := urlWithPath(*u, "/oauth/token")
-	v := url.Values{}
-	v.Add("token_format", tokenFormat.String())
-	c := &passwordcredentials.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		Username:     username,
-		Password:     password,
-		Endpoint: oauth2.Endpoint{
-			TokenURL: tokenURL.String(),
-		},
-		EndpointParams: v,
-	}
-	client := &http.Client{Transport: http.DefaultTransport}
-	return &API{
-		UnauthenticatedClient: client,
-		AuthenticatedClient:   c.Client(context.WithValue(context.Background(), oauth2.HTTPClient, client)),
-		TargetURL:             u,
-		ZoneID:                zoneID,
-	}, nil
-}
+	a := &API{TargetURL: u}
+	applyOptions(a, opts)
 
-// NewWithAuthorizationCode builds an API that uses the authorization code
-// grant to get a token for use with the UAA API.
+	a.UnauthenticatedClient = a.buildUnauthenticatedClient()
 
-func NewWithAuthorizationCode(target string, zoneID string, clientID string, clientSecret string, code string, skipSSLValidation bool, tokenFormat TokenFormat) (*API, error) {
-	url, err := BuildTargetURL(target)
-	if err != nil {
-		return nil, err
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.UnauthenticatedClient)
 
-	tokenURL := urlWithPath(*url, "/oauth/token")
-	c := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		Endpoint: oauth2.Endpoint{
-			TokenURL: tokenURL.String(),
-		},
-	}
+	tokenURL := urlWithPath(*u, "/oauth/token")
 
-	client := &http.Client{Transport: http.DefaultTransport}
-	a := &API{
-		UnauthenticatedClient: client,
-		TargetURL:             url,
-		SkipSSLValidation:     skipSSLValidation,
-		ZoneID:                zoneID,
+	switch {
+	case a.token != nil:
+		if a.token.AccessToken == "" || a.token.Expiry.Before(time.Now()) {
+			return nil, errors.New("must supply a valid token")
+		}
+		transport := a.UnauthenticatedClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		a.AuthenticatedClient = &http.Client{
+			Transport: &tokenTransport{underlyingTransport: transport, token: *a.token},
+		}
+		a.tokenSource = oauth2.StaticTokenSource(a.token)
+	case a.refreshToken != "":
+		query := tokenURL.Query()
+		query.Set("token_format", a.tokenFormat.String())
+		tokenURL.RawQuery = query.Encode()
+		c := &oauth2.Config{
+			ClientID:     a.clientID,
+			ClientSecret: a.clientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: tokenURL.String()},
+		}
+		tokenSource := a.wrapTokenSource(c.TokenSource(ctx, &oauth2.Token{RefreshToken: a.refreshToken}))
+		if _, err := tokenSource.Token(); err != nil {
+			return nil, err
+		}
+		a.AuthenticatedClient = oauth2.NewClient(ctx, tokenSource)
+	case a.code != "":
+		c := &oauth2.Config{
+			ClientID:     a.clientID,
+			ClientSecret: a.clientSecret,
+			RedirectURL:  a.redirectURI,
+			Endpoint:     oauth2.Endpoint{TokenURL: tokenURL.String()},
+		}
+		exchangeParams := []oauth2.AuthCodeOption{
+			oauth2.SetAuthURLParam("token_format", a.tokenFormat.String()),
+			oauth2.SetAuthURLParam("response_type", "token"),
+		}
+		if a.codeVerifier != "" {
+			exchangeParams = append(exchangeParams, oauth2.SetAuthURLParam("code_verifier", a.codeVerifier))
+		}
+		t, err := c.Exchange(ctx, a.code, exchangeParams...)
+		if err != nil {
+			return nil, err
+		}
+		a.AuthenticatedClient = oauth2.NewClient(ctx, a.wrapTokenSource(c.TokenSource(ctx, t)))
+	case a.username != "":
+		v := url.Values{}
+		v.Add("token_format", a.tokenFormat.String())
+		c := &passwordcredentials.Config{
+			ClientID:       a.clientID,
+			ClientSecret:   a.clientSecret,
+			Username:       a.username,
+			Password:       a.password,
+			Endpoint:       oauth2.Endpoint{TokenURL: tokenURL.String()},
+			EndpointParams: v,
+		}
+		a.AuthenticatedClient = oauth2.NewClient(ctx, a.wrapTokenSource(c.TokenSource(ctx)))
+	case a.clientID != "":
+		v := url.Values{}
+		v.Add("token_format", a.tokenFormat.String())
+		c := &clientcredentials.Config{
+			ClientID:       a.clientID,
+			ClientSecret:   a.clientSecret,
+			TokenURL:       tokenURL.String(),
+			EndpointParams: v,
+		}
+		a.AuthenticatedClient = oauth2.NewClient(ctx, a.wrapTokenSource(c.TokenSource(ctx)))
+	default:
+		return nil, errors.New("must supply credentials via an Option, e.g. WithClientCredentials")
 	}
-	a.ensureTransport(a.UnauthenticatedClient)
-	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, a.UnauthenticatedClient)
-	tokenFormatParam := oauth2.SetAuthURLParam("token_format", tokenFormat.String())
-	responseTypeParam := oauth2.SetAuthURLParam("response_type", "token")
 
-	t, err := c.Exchange(ctx, code, tokenFormatParam, responseTypeParam)
-	if err != nil {
-		return nil, err
-	}
+	return a, nil
+}
 
-	a.AuthenticatedClient = c.Client(ctx, t)
+// NewWithToken builds an API that uses the given token to make authenticated
+// requests to the UAA API.
+func NewWithToken(target string, zoneID string, token oauth2.Token) (*API, error) {
+	return New(target, WithZoneID(zoneID), WithToken(token))
+}
 
-	return a, nil
+// NewWithClientCredentials builds an API that uses the client credentials grant
+// to get a token for use with the UAA API.
+func NewWithClientCredentials(target string, zoneID string, clientID string, clientSecret string, tokenFormat TokenFormat) (*API, error) {
+	return New(target, WithZoneID(zoneID), WithClientCredentials(clientID, clientSecret), WithTokenFormat(tokenFormat))
 }
 
-// NewWithRefreshToken builds an API that uses the given refresh token to get an
-// access token for use with the UAA API.
-func NewWithRefreshToken(target string, zoneID string, clientID string, clientSecret string, refreshToken string, skipSSLValidation bool, tokenFormat TokenFormat) (*API, error) {
-	url, err := BuildTargetURL(target)
-	if err != nil {
-		return nil, err
+// NewWithPasswordCredentials builds an API that uses the password credentials
+// grant to get a token for use with the UAA API.
+func NewWithPasswordCredentials(target string, zoneID string, clientID string, clientSecret string, username string, password string, tokenFormat TokenFormat, opts ...Option) (*API, error) {
+	base := []Option{
+		WithZoneID(zoneID),
+		WithClientCredentials(clientID, clientSecret),
+		WithPasswordCredentials(username, password),
+		WithTokenFormat(tokenFormat),
 	}
-	tokenURL := urlWithPath(*url, "/oauth/token")
-	query := tokenURL.Query()
-	query.Set("token_format", tokenFormat.String())
-	tokenURL.RawQuery = query.Encode()
+	return New(target, append(base, opts...)...)
+}
 
-	c := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		Endpoint: oauth2.Endpoint{
-			TokenURL: tokenURL.String(),
-		},
+// NewWithAuthorizationCode builds an API that uses the authorization code
+// grant to get a token for use with the UAA API.
+func NewWithAuthorizationCode(target string, zoneID string, clientID string, clientSecret string, code string, skipSSLValidation bool, tokenFormat TokenFormat, opts ...Option) (*API, error) {
+	base := []Option{
+		WithZoneID(zoneID),
+		WithClientCredentials(clientID, clientSecret),
+		WithAuthorizationCode(code, ""),
+		WithTokenFormat(tokenFormat),
 	}
-
-	a := &API{
-		UnauthenticatedClient: &http.Client{Transport: http.DefaultTransport},
-		TargetURL:             url,
-		SkipSSLValidation:     skipSSLValidation,
-		ZoneID:                zoneID,
+	if skipSSLValidation {
+		base = append(base, WithSkipSSLValidation())
 	}
+	return New(target, append(base, opts...)...)
+}
 
-	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, a.UnauthenticatedClient)
-	tokenSource := c.TokenSource(ctx, &oauth2.Token{
-		RefreshToken: refreshToken,
-	})
-
-	token, err := tokenSource.Token()
-	if err != nil {
-		return nil, err
+// NewWithRefreshToken builds an API that uses the given refresh token to get an
+// access token for use with the UAA API.
+func NewWithRefreshToken(target string, zoneID string, clientID string, clientSecret string, refreshToken string, skipSSLValidation bool, tokenFormat TokenFormat, opts ...Option) (*API, error) {
+	base := []Option{
+		WithZoneID(zoneID),
+		WithClientCredentials(clientID, clientSecret),
+		WithRefreshToken(refreshToken),
+		WithTokenFormat(tokenFormat),
 	}
-
-	a.AuthenticatedClient = c.Client(ctx, token)
-
-	return a, nil
+	if skipSSLValidation {
+		base = append(base, WithSkipSSLValidation())
+	}
+	return New(target, append(base, opts...)...)
 }
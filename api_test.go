@@ -0,0 +1,118 @@
+package uaa
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newTokenServer starts a test server that answers every /oauth/token
+// request with a fixed token response, regardless of grant_type, and
+// records the form values of the last request it served.
+func newTokenServer(t *testing.T) (*httptest.Server, *url.Values) {
+	t.Helper()
+	var lastForm url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("could not parse form: %v", err)
+		}
+		lastForm = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-token",
+			"refresh_token": "refresh-token",
+			"token_type":    "bearer",
+			"expires_in":    3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &lastForm
+}
+
+func TestNewClientCredentials(t *testing.T) {
+	server, _ := newTokenServer(t)
+	a, err := New(server.URL, WithClientCredentials("client-id", "client-secret"))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	if a.AuthenticatedClient == nil {
+		t.Fatal("New() did not set AuthenticatedClient")
+	}
+}
+
+func TestNewPasswordCredentials(t *testing.T) {
+	server, _ := newTokenServer(t)
+	a, err := New(server.URL,
+		WithClientCredentials("client-id", "client-secret"),
+		WithPasswordCredentials("username", "password"),
+	)
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	if a.AuthenticatedClient == nil {
+		t.Fatal("New() did not set AuthenticatedClient")
+	}
+}
+
+func TestNewAuthorizationCode(t *testing.T) {
+	server, _ := newTokenServer(t)
+	a, err := New(server.URL,
+		WithClientCredentials("client-id", "client-secret"),
+		WithAuthorizationCode("the-code", "https://example.com/callback"),
+	)
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	if a.AuthenticatedClient == nil {
+		t.Fatal("New() did not set AuthenticatedClient")
+	}
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	server, _ := newTokenServer(t)
+	a, err := New(server.URL,
+		WithClientCredentials("client-id", "client-secret"),
+		WithRefreshToken("the-refresh-token"),
+	)
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	if a.AuthenticatedClient == nil {
+		t.Fatal("New() did not set AuthenticatedClient")
+	}
+}
+
+func TestNewWithToken(t *testing.T) {
+	a, err := New("https://uaa.example.com", WithToken(oauth2.Token{
+		AccessToken: "access-token",
+		Expiry:      time.Now().Add(time.Hour),
+	}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	if a.AuthenticatedClient == nil {
+		t.Fatal("New() did not set AuthenticatedClient")
+	}
+}
+
+func TestNewWithTokenRejectsExpiredToken(t *testing.T) {
+	_, err := New("https://uaa.example.com", WithToken(oauth2.Token{
+		AccessToken: "access-token",
+		Expiry:      time.Now().Add(-time.Hour),
+	}))
+	if err == nil {
+		t.Fatal("expected an error for an already-expired token")
+	}
+}
+
+func TestNewRequiresCredentials(t *testing.T) {
+	_, err := New("https://uaa.example.com")
+	if err == nil {
+		t.Fatal("expected an error when no credentials Option is supplied")
+	}
+}
@@ -0,0 +1,230 @@
+package uaa
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// clientAssertionLifetime is how long a client assertion JWT is valid for
+// before it must be re-signed. UAA rejects assertions with a stale exp, so
+// this is kept short.
+const clientAssertionLifetime = 5 * time.Minute
+
+// NewWithJWTBearer builds an API that authenticates with a signed JWT client
+// assertion (RFC 7523 "private_key_jwt") instead of a shared client_secret.
+// signer and keyID identify the key UAA has been configured with via JWKS;
+// the assertion is re-signed every time the token is refreshed. By default
+// the client authenticates itself via client_credentials; pass WithAssertion
+// to instead exchange a user assertion via
+// urn:ietf:params:oauth:grant-type:jwt-bearer.
+func NewWithJWTBearer(target string, zoneID string, clientID string, signer crypto.Signer, keyID string, opts ...Option) (*API, error) {
+	u, err := BuildTargetURL(target)
+	if err != nil {
+		return nil, err
+	}
+	tokenURL := urlWithPath(*u, "/oauth/token")
+
+	a := &API{TargetURL: u, ZoneID: zoneID}
+	applyOptions(a, opts)
+
+	a.UnauthenticatedClient = a.buildUnauthenticatedClient()
+
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.UnauthenticatedClient)
+
+	source := &jwtBearerTokenSource{
+		ctx:         ctx,
+		client:      a.UnauthenticatedClient,
+		tokenURL:    tokenURL.String(),
+		clientID:    clientID,
+		signer:      signer,
+		keyID:       keyID,
+		tokenFormat: a.tokenFormat,
+		assertion:   a.assertion,
+	}
+
+	a.AuthenticatedClient = oauth2.NewClient(ctx, a.wrapTokenSource(oauth2.ReuseTokenSource(nil, source)))
+
+	return a, nil
+}
+
+// jwtBearerTokenSource requests a token from the UAA token endpoint using a
+// freshly-signed client assertion on every call, so it is always wrapped in
+// an oauth2.ReuseTokenSource by its caller.
+type jwtBearerTokenSource struct {
+	ctx         context.Context
+	client      *http.Client
+	tokenURL    string
+	clientID    string
+	signer      crypto.Signer
+	keyID       string
+	tokenFormat TokenFormat
+	assertion   string
+}
+
+func (s *jwtBearerTokenSource) Token() (*oauth2.Token, error) {
+	clientAssertion, err := signClientAssertion(s.signer, s.keyID, s.clientID, s.tokenURL)
+	if err != nil {
+		return nil, err
+	}
+
+	v := url.Values{}
+	v.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	v.Set("client_assertion", clientAssertion)
+	v.Set("token_format", s.tokenFormat.String())
+	if s.assertion != "" {
+		v.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		v.Set("assertion", s.assertion)
+	} else {
+		v.Set("grant_type", "client_credentials")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.tokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(s.ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s: %s", resp.Status, body)
+	}
+
+	var tokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("could not decode token response: %w", err)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  tokenResponse.AccessToken,
+		RefreshToken: tokenResponse.RefreshToken,
+		TokenType:    tokenResponse.TokenType,
+	}
+	if tokenResponse.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// signClientAssertion builds and signs the RFC 7523 client assertion JWT UAA
+// expects for private_key_jwt: iss and sub are clientID, aud is the token
+// endpoint, and jti/iat/exp guard against replay.
+func signClientAssertion(signer crypto.Signer, keyID string, clientID string, audience string) (string, error) {
+	alg, err := jwtAlgForSigner(signer)
+	if err != nil {
+		return "", err
+	}
+
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+	jti, err := randomURLSafeString(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": audience,
+		"jti": jti,
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal client assertion header: %w", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal client assertion claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hashed := sha256.Sum256([]byte(signingInput))
+	sig, err := signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("could not sign client assertion: %w", err)
+	}
+	if _, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		sig, err = ecdsaDERToJWS(sig)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// jwtAlgForSigner picks the JWS alg corresponding to signer's key type. Only
+// the key types UAA's JWKS support are recognized. ECDSA keys must be
+// P-256: ecdsaDERToJWS below assumes a 32-byte R/S component size to match
+// the ES256 alg returned here, and would panic on a P-384 or P-521 key.
+func jwtAlgForSigner(signer crypto.Signer) (string, error) {
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return "", errors.New("unsupported client assertion signer: ECDSA key must use curve P-256")
+		}
+		return "ES256", nil
+	default:
+		return "", errors.New("unsupported client assertion signer: must be RSA or ECDSA (P-256)")
+	}
+}
+
+// ecdsaDERToJWS converts the ASN.1 DER signature produced by
+// (*ecdsa.PrivateKey).Sign into the fixed-width r||s encoding a JWS requires.
+// It assumes a P-256 key, matching the ES256 alg chosen by jwtAlgForSigner.
+func ecdsaDERToJWS(der []byte) ([]byte, error) {
+	var parsed struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse ECDSA client assertion signature: %w", err)
+	}
+	const componentSize = 32 // P-256
+	out := make([]byte, 2*componentSize)
+	parsed.R.FillBytes(out[:componentSize])
+	parsed.S.FillBytes(out[componentSize:])
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package uaa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestEcdsaDERToJWSRoundTrips(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	hashed := []byte("12345678901234567890123456789012") // 32 bytes
+	der, err := key.Sign(rand.Reader, hashed, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	jws, err := ecdsaDERToJWS(der)
+	if err != nil {
+		t.Fatalf("ecdsaDERToJWS() returned error: %v", err)
+	}
+	if len(jws) != 64 {
+		t.Fatalf("expected a 64-byte r||s signature, got %d bytes", len(jws))
+	}
+
+	var parsed struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &parsed); err != nil {
+		t.Fatalf("asn1.Unmarshal: %v", err)
+	}
+	if new(big.Int).SetBytes(jws[:32]).Cmp(parsed.R) != 0 {
+		t.Fatal("r component was not preserved")
+	}
+	if new(big.Int).SetBytes(jws[32:]).Cmp(parsed.S) != 0 {
+		t.Fatal("s component was not preserved")
+	}
+}
+
+func TestJwtAlgForSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	if alg, err := jwtAlgForSigner(rsaKey); err != nil || alg != "RS256" {
+		t.Fatalf("jwtAlgForSigner(rsa) = %q, %v; want RS256, nil", alg, err)
+	}
+
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(P256): %v", err)
+	}
+	if alg, err := jwtAlgForSigner(p256Key); err != nil || alg != "ES256" {
+		t.Fatalf("jwtAlgForSigner(P-256) = %q, %v; want ES256, nil", alg, err)
+	}
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(P384): %v", err)
+	}
+	if _, err := jwtAlgForSigner(p384Key); err == nil {
+		t.Fatal("jwtAlgForSigner(P-384) should have returned an error, not claimed ES256")
+	}
+}
+
+func TestSignClientAssertionRejectsNonP256ECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(P521): %v", err)
+	}
+	if _, err := signClientAssertion(key, "", "client-id", "https://uaa.example.com/oauth/token"); err == nil {
+		t.Fatal("signClientAssertion should reject a P-521 signer instead of panicking in ecdsaDERToJWS")
+	}
+}
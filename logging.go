@@ -0,0 +1,108 @@
+package uaa
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+)
+
+// WithLogger returns an Option that causes the API to dump every HTTP
+// request and response it sends to w whenever Verbose is true. Sensitive
+// values are redacted first; see redact.
+func WithLogger(w io.Writer) Option {
+	return func(a *API) {
+		a.logger = log.New(w, "", log.LstdFlags)
+	}
+}
+
+// loggingTransport dumps requests/responses to api.logger when api.Verbose is
+// true, and invokes api.RequestHook/api.ResponseHook unconditionally. Both
+// are read from api at RoundTrip time, so toggling Verbose or assigning a
+// hook after construction takes effect immediately.
+type loggingTransport struct {
+	api        *API
+	underlying http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.api.RequestHook != nil {
+		t.api.RequestHook(req)
+	}
+	if t.api.Verbose && t.api.logger != nil {
+		if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+			t.api.logger.Printf("--> %s", redact(dump))
+		}
+	}
+
+	underlying := t.underlying
+	if underlying == nil {
+		underlying = http.DefaultTransport
+	}
+	resp, err := underlying.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if t.api.Verbose && t.api.logger != nil {
+		if dump, err := httputil.DumpResponse(resp, true); err == nil {
+			t.api.logger.Printf("<-- %s", redact(dump))
+		}
+	}
+	if t.api.ResponseHook != nil {
+		t.api.ResponseHook(resp)
+	}
+
+	return resp, nil
+}
+
+// sensitiveFields lists the form/JSON field names that must never reach a log
+// verbatim; sensitive headers are matched by sensitiveHeaders instead. The cf CLI's equivalent request dumper has
+// repeatedly leaked tokens this way, so this list is deliberately broad.
+var sensitiveFields = []string{
+	"client_secret",
+	"password",
+	"code",
+	"code_verifier",
+	"refresh_token",
+	"access_token",
+	"assertion",
+	"client_assertion",
+	"passcode",
+}
+
+var sensitiveHeaders = regexp.MustCompile(`(?im)^(Authorization|Set-Cookie|Cookie):.*$`)
+
+// sensitiveFormFields matches "key=value" pairs in a form-encoded body. The
+// leading group anchors on the start of the text, whitespace (such as the
+// line break before a dumped body) or a "&"/"?" separator so that, e.g., the "code" pattern doesn't also match inside "passcode=...".
+var sensitiveFormFields = buildSensitiveFieldPatterns(`(?i)(^|[&?\s])(%s)=[^&\s]*`)
+
+// sensitiveJSONFields matches quoted "key": "value" pairs. Quoting the field
+// name anchors it, so "assertion" doesn't also match inside
+// "client_assertion".
+var sensitiveJSONFields = buildSensitiveFieldPatterns(`(?i)("%s"\s*:\s*")[^"]*(")`)
+
+func buildSensitiveFieldPatterns(format string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(sensitiveFields))
+	for i, field := range sensitiveFields {
+		patterns[i] = regexp.MustCompile(fmt.Sprintf(format, regexp.QuoteMeta(field)))
+	}
+	return patterns
+}
+
+// redact scrubs a dumped HTTP request/response (headers and body alike) of
+// Authorization/Set-Cookie/Cookie headers and any sensitive form-encoded or
+// JSON field, replacing values with [REDACTED].
+func redact(dump []byte) []byte {
+	out := sensitiveHeaders.ReplaceAll(dump, []byte("$1: [REDACTED]"))
+	for _, pattern := range sensitiveFormFields {
+		out = pattern.ReplaceAll(out, []byte("${1}${2}=[REDACTED]"))
+	}
+	for _, pattern := range sensitiveJSONFields {
+		out = pattern.ReplaceAll(out, []byte("${1}[REDACTED]${2}"))
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package uaa
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactScrubsSensitiveHeaders(t *testing.T) {
+	dump := []byte("GET /oauth/token HTTP/1.1\r\n" +
+		"Authorization: Bearer abc123\r\n" +
+		"Cookie: JSESSIONID=secret\r\n" +
+		"Set-Cookie: JSESSIONID=secret; Path=/\r\n" +
+		"Accept: application/json\r\n")
+
+	out := string(redact(dump))
+	if strings.Contains(out, "abc123") || strings.Contains(out, "secret") {
+		t.Fatalf("redact() left a sensitive header value in the output: %s", out)
+	}
+	if !strings.Contains(out, "Authorization: [REDACTED]") {
+		t.Fatalf("redact() did not redact the Authorization header: %s", out)
+	}
+	if !strings.Contains(out, "Accept: application/json") {
+		t.Fatalf("redact() altered a non-sensitive header: %s", out)
+	}
+}
+
+func TestRedactScrubsFormFields(t *testing.T) {
+	body := []byte("grant_type=password&client_secret=shh&code=abc&passcode=123456&token_format=opaque")
+
+	out := string(redact(body))
+	if strings.Contains(out, "shh") || strings.Contains(out, "=abc&") || strings.Contains(out, "123456") {
+		t.Fatalf("redact() left a sensitive form value in the output: %s", out)
+	}
+	if !strings.Contains(out, "token_format=opaque") {
+		t.Fatalf("redact() altered a non-sensitive form field: %s", out)
+	}
+}
+
+func TestRedactDoesNotMatchCodeInsideUnrelatedField(t *testing.T) {
+	body := []byte("zipcode=90210")
+	out := string(redact(body))
+	if out != "zipcode=90210" {
+		t.Fatalf("redact() incorrectly matched \"code\" inside \"zipcode\": %s", out)
+	}
+}
+
+func TestRedactScrubsJSONFields(t *testing.T) {
+	body := []byte(`{"access_token":"abc123","client_assertion":"xyz","assertion":"abc","token_type":"bearer"}`)
+
+	out := string(redact(body))
+	if strings.Contains(out, "abc123") || strings.Contains(out, "xyz") {
+		t.Fatalf("redact() left a sensitive JSON value in the output: %s", out)
+	}
+	if !strings.Contains(out, `"token_type":"bearer"`) {
+		t.Fatalf("redact() altered a non-sensitive JSON field: %s", out)
+	}
+	if !strings.Contains(out, `"assertion":"[REDACTED]"`) {
+		t.Fatalf("redact() must redact \"assertion\" on its own, not only inside \"client_assertion\": %s", out)
+	}
+}
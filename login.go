@@ -0,0 +1,204 @@
+package uaa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Prompt describes a single field the UAA /login endpoint asks for before a
+// password grant can succeed, e.g. a username, password, passcode, or MFA
+// code. Type is a form input type such as "text" or "password"; Label is the
+// human-readable prompt to show the user.
+type Prompt struct {
+	Type  string
+	Label string
+}
+
+// UnmarshalJSON unmarshals a Prompt from the ["type", "label"] array the
+// UAA /login endpoint encodes it as.
+func (p *Prompt) UnmarshalJSON(data []byte) error {
+	var fields [2]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	p.Type = fields[0]
+	p.Label = fields[1]
+	return nil
+}
+
+// LoginPrompts fetches the authentication prompts the UAA is configured to
+// collect, keyed by field name (e.g. "username", "password", "passcode").
+// Querying this before asking the resource owner for credentials is how
+// callers support MFA- and SAML-backed zones without hard-coding a
+// username/password pair.
+func (a *API) LoginPrompts(ctx context.Context) (map[string]Prompt, error) {
+	loginURL := urlWithPath(*a.TargetURL, "/login")
+	req, err := http.NewRequest(http.MethodGet, loginURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.UnauthenticatedClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not request login prompts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read login response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("login endpoint returned %s: %s", resp.Status, body)
+	}
+
+	var login struct {
+		Prompts map[string]Prompt `json:"prompts"`
+	}
+	if err := json.Unmarshal(body, &login); err != nil {
+		return nil, fmt.Errorf("could not decode login response: %w", err)
+	}
+	return login.Prompts, nil
+}
+
+// NewWithPrompts builds an API using the password grant, submitting answers
+// as the form fields the UAA's /login prompts asked for (for example
+// "username", "password", "passcode", or an MFA code) rather than assuming a
+// static username/password pair. Callers typically obtain answers by calling
+// LoginPrompts first and collecting a response for each prompt.
+func NewWithPrompts(target string, zoneID string, clientID string, clientSecret string, answers map[string]string, tokenFormat TokenFormat, opts ...Option) (*API, error) {
+	u, err := BuildTargetURL(target)
+	if err != nil {
+		return nil, err
+	}
+	tokenURL := urlWithPath(*u, "/oauth/token")
+
+	a := &API{TargetURL: u, ZoneID: zoneID}
+	applyOptions(a, opts)
+
+	a.UnauthenticatedClient = a.buildUnauthenticatedClient()
+
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.UnauthenticatedClient)
+
+	source := &promptTokenSource{
+		ctx:          ctx,
+		client:       a.UnauthenticatedClient,
+		tokenURL:     tokenURL.String(),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		answers:      answers,
+		tokenFormat:  tokenFormat,
+	}
+
+	token, err := source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	a.AuthenticatedClient = oauth2.NewClient(ctx, a.wrapTokenSource(oauth2.ReuseTokenSource(token, source)))
+
+	return a, nil
+}
+
+// promptTokenSource requests a token using the password grant, forwarding
+// whatever prompt answers the caller collected as additional form fields
+// (passcode, MFA code, etc). Those answers are often one-time or
+// time-boxed (an MFA passcode, say), so they are only usable for the
+// initial login: once the UAA has handed back a refresh_token, Token
+// re-authenticates with the standard refresh_token grant instead of
+// resubmitting the original answers.
+type promptTokenSource struct {
+	mu           sync.Mutex
+	ctx          context.Context
+	client       *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	answers      map[string]string
+	tokenFormat  TokenFormat
+	refreshToken string
+}
+
+func (s *promptTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	refreshToken := s.refreshToken
+	s.mu.Unlock()
+
+	v := url.Values{}
+	v.Set("response_type", "token")
+	v.Set("token_format", s.tokenFormat.String())
+	if refreshToken != "" {
+		v.Set("grant_type", "refresh_token")
+		v.Set("refresh_token", refreshToken)
+	} else {
+		v.Set("grant_type", "password")
+		for field, answer := range s.answers {
+			v.Set(field, answer)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.tokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(s.ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(s.clientID, s.clientSecret)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s: %s", resp.Status, body)
+	}
+
+	var tokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("could not decode token response: %w", err)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  tokenResponse.AccessToken,
+		RefreshToken: tokenResponse.RefreshToken,
+		TokenType:    tokenResponse.TokenType,
+	}
+	if tokenResponse.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+
+	if tokenResponse.RefreshToken != "" {
+		s.mu.Lock()
+		s.refreshToken = tokenResponse.RefreshToken
+		s.mu.Unlock()
+	}
+
+	return token, nil
+}
@@ -0,0 +1,120 @@
+package uaa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPromptUnmarshalJSON(t *testing.T) {
+	var p Prompt
+	if err := json.Unmarshal([]byte(`["password","Password"]`), &p); err != nil {
+		t.Fatalf("UnmarshalJSON() returned error: %v", err)
+	}
+	if p.Type != "password" || p.Label != "Password" {
+		t.Fatalf("got %+v, want Type=password Label=Password", p)
+	}
+}
+
+func TestLoginPrompts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/login" {
+			t.Fatalf("unexpected path %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"prompts":{"username":["text","Username"],"passcode":["password","One Time Code"]}}`))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	a := &API{TargetURL: u, UnauthenticatedClient: server.Client()}
+
+	prompts, err := a.LoginPrompts(context.Background())
+	if err != nil {
+		t.Fatalf("LoginPrompts() returned error: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(prompts))
+	}
+	if prompts["passcode"].Type != "password" || prompts["passcode"].Label != "One Time Code" {
+		t.Fatalf("unexpected passcode prompt: %+v", prompts["passcode"])
+	}
+}
+
+func TestPromptTokenSourceRefreshesWithRefreshTokenNotAnswers(t *testing.T) {
+	var forms []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("could not parse form: %v", err)
+		}
+		forms = append(forms, r.PostForm)
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("grant_type") == "refresh_token" {
+			_, _ = w.Write([]byte(`{"access_token":"access-2","refresh_token":"refresh-2","token_type":"bearer","expires_in":3600}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"access-1","refresh_token":"refresh-1","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	source := &promptTokenSource{
+		ctx:          context.Background(),
+		client:       server.Client(),
+		tokenURL:     server.URL,
+		clientID:     "client-id",
+		clientSecret: "client-secret",
+		answers:      map[string]string{"passcode": "123456"},
+	}
+
+	token1, err := source.Token()
+	if err != nil {
+		t.Fatalf("first Token() returned error: %v", err)
+	}
+	if token1.AccessToken != "access-1" {
+		t.Fatalf("unexpected access token %q", token1.AccessToken)
+	}
+
+	token2, err := source.Token()
+	if err != nil {
+		t.Fatalf("second Token() returned error: %v", err)
+	}
+	if token2.AccessToken != "access-2" {
+		t.Fatalf("unexpected access token %q", token2.AccessToken)
+	}
+
+	if len(forms) != 2 {
+		t.Fatalf("expected 2 requests to the token endpoint, got %d", len(forms))
+	}
+	if forms[0].Get("grant_type") != "password" || forms[0].Get("passcode") != "123456" {
+		t.Fatalf("first request should have used the password grant with the prompt answers: %v", forms[0])
+	}
+	if forms[1].Get("grant_type") != "refresh_token" || forms[1].Get("refresh_token") != "refresh-1" {
+		t.Fatalf("second request should have used the refresh_token grant with the token from the first response: %v", forms[1])
+	}
+	if forms[1].Get("passcode") != "" {
+		t.Fatalf("second request must not resubmit the one-time passcode: %v", forms[1])
+	}
+}
+
+func TestNewWithPrompts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access-1","refresh_token":"refresh-1","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	a, err := NewWithPrompts(server.URL, "zone-id", "client-id", "client-secret", map[string]string{"passcode": "123456"}, OpaqueToken)
+	if err != nil {
+		t.Fatalf("NewWithPrompts() returned error: %v", err)
+	}
+	if a.AuthenticatedClient == nil {
+		t.Fatal("NewWithPrompts() did not set AuthenticatedClient")
+	}
+}
@@ -0,0 +1,115 @@
+package uaa
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// WithClientCredentials returns an Option that sets the client ID and secret
+// New uses to authenticate at the token endpoint. This is required by every
+// grant UAA supports, not only the client_credentials grant itself, so it
+// composes with WithPasswordCredentials, WithAuthorizationCode and
+// WithRefreshToken.
+func WithClientCredentials(clientID string, clientSecret string) Option {
+	return func(a *API) {
+		a.clientID = clientID
+		a.clientSecret = clientSecret
+	}
+}
+
+// WithPasswordCredentials returns an Option that causes New to use the
+// password credentials grant, authenticating as username and password.
+func WithPasswordCredentials(username string, password string) Option {
+	return func(a *API) {
+		a.username = username
+		a.password = password
+	}
+}
+
+// WithAuthorizationCode returns an Option that causes New to exchange code
+// for a token using the authorization code grant. redirectURI must match the
+// redirect_uri that was used to obtain code, and may be empty if none was
+// supplied.
+func WithAuthorizationCode(code string, redirectURI string) Option {
+	return func(a *API) {
+		a.code = code
+		a.redirectURI = redirectURI
+	}
+}
+
+// WithRefreshToken returns an Option that causes New to exchange refreshToken
+// for an access token.
+func WithRefreshToken(refreshToken string) Option {
+	return func(a *API) {
+		a.refreshToken = refreshToken
+	}
+}
+
+// WithToken returns an Option that causes New to make authenticated requests
+// using the given token directly, without an OAuth2 grant.
+func WithToken(token oauth2.Token) Option {
+	return func(a *API) {
+		a.token = &token
+	}
+}
+
+// WithZoneID returns an Option that sets the X-Identity-Zone-Id used for
+// zone-switching requests.
+func WithZoneID(zoneID string) Option {
+	return func(a *API) {
+		a.ZoneID = zoneID
+	}
+}
+
+// WithSkipSSLValidation returns an Option that disables TLS certificate
+// validation. It should only be used against test UAA deployments.
+func WithSkipSSLValidation() Option {
+	return func(a *API) {
+		a.SkipSSLValidation = true
+	}
+}
+
+// WithTokenFormat returns an Option that sets the token_format requested at
+// the token endpoint.
+func WithTokenFormat(tokenFormat TokenFormat) Option {
+	return func(a *API) {
+		a.tokenFormat = tokenFormat
+	}
+}
+
+// WithHTTPClient returns an Option that makes New use client as the base for
+// both the authenticated and unauthenticated clients, instead of building one
+// from http.DefaultTransport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *API) {
+		a.httpClient = client
+	}
+}
+
+// WithUserAgent returns an Option that sets the User-Agent header sent with
+// every request.
+func WithUserAgent(userAgent string) Option {
+	return func(a *API) {
+		a.userAgent = userAgent
+	}
+}
+
+// WithContext returns an Option that sets the base context.Context New uses
+// to build its OAuth2 TokenSource, instead of context.Background().
+func WithContext(ctx context.Context) Option {
+	return func(a *API) {
+		a.ctx = ctx
+	}
+}
+
+// WithAssertion returns an Option that causes NewWithJWTBearer to request the
+// urn:ietf:params:oauth:grant-type:jwt-bearer grant with the given user
+// assertion, instead of authenticating as the client alone via
+// client_credentials.
+func WithAssertion(assertion string) Option {
+	return func(a *API) {
+		a.assertion = assertion
+	}
+}
@@ -0,0 +1,154 @@
+package uaa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+)
+
+// OnAuthURL returns an Option that, when passed to
+// NewWithAuthorizationCodeFlow, causes f to be called with the
+// /oauth/authorize URL instead of opening it in a browser. Use this in
+// headless environments where the URL must be handed to the user some other
+// way, e.g. printed to a terminal or relayed over SSH.
+func OnAuthURL(f func(string)) Option {
+	return func(a *API) {
+		a.onAuthURL = f
+	}
+}
+
+// WithCodeVerifier returns an Option that supplies the PKCE code_verifier to
+// send alongside WithAuthorizationCode when New exchanges a code for a
+// token. NewWithAuthorizationCodeFlow sets this itself; callers driving their
+// own PKCE exchange can use it directly with New.
+func WithCodeVerifier(codeVerifier string) Option {
+	return func(a *API) {
+		a.codeVerifier = codeVerifier
+	}
+}
+
+// NewWithAuthorizationCodeFlow drives the authorization code grant with PKCE
+// (RFC 7636) end-to-end: it starts an HTTP server on an ephemeral loopback
+// port, opens the UAA /oauth/authorize URL in the user's browser (or passes
+// it to an OnAuthURL option for headless use), waits for the browser to
+// redirect back with an authorization code, and exchanges that code for a
+// token. It is intended for CLIs and other public clients that cannot safely
+// hold a client_secret.
+func NewWithAuthorizationCodeFlow(ctx context.Context, target string, clientID string, opts ...Option) (*API, error) {
+	u, err := BuildTargetURL(target)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, challenge, err := generatePKCE()
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomURLSafeString(24)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not start loopback listener: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("error") != "":
+			errCh <- fmt.Errorf("authorization failed: %s", q.Get("error"))
+		case q.Get("state") != state:
+			errCh <- errors.New("authorization response had an unexpected state value")
+		case q.Get("code") == "":
+			errCh <- errors.New("authorization response did not include a code")
+		default:
+			fmt.Fprintln(w, "Authorization complete. You may close this window.")
+			codeCh <- q.Get("code")
+			return
+		}
+		fmt.Fprintln(w, "Authorization failed. You may close this window.")
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authorizeURL := urlWithPath(*u, "/oauth/authorize")
+	q := authorizeURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	authorizeURL.RawQuery = q.Encode()
+
+	a := &API{}
+	applyOptions(a, opts)
+	if a.onAuthURL != nil {
+		a.onAuthURL(authorizeURL.String())
+	} else if err := openBrowser(authorizeURL.String()); err != nil {
+		return nil, fmt.Errorf("could not open a browser, use OnAuthURL to retrieve the URL instead: %w", err)
+	}
+
+	var code string
+	select {
+	case code = <-codeCh:
+	case err := <-errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	base := []Option{
+		WithContext(ctx),
+		WithClientCredentials(clientID, ""),
+		WithAuthorizationCode(code, redirectURI),
+		WithCodeVerifier(verifier),
+	}
+	return New(target, append(base, opts...)...)
+}
+
+// generatePKCE returns a random code_verifier and its S256 code_challenge, as
+// described by RFC 7636.
+func generatePKCE() (verifier string, challenge string, err error) {
+	verifier, err = randomURLSafeString(64)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	return verifier, challenge, nil
+}
+
+func randomURLSafeString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// openBrowser opens url in the user's default browser.
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
@@ -0,0 +1,37 @@
+package uaa
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestGeneratePKCEChallengeMatchesVerifier(t *testing.T) {
+	verifier, challenge, err := generatePKCE()
+	if err != nil {
+		t.Fatalf("generatePKCE() returned error: %v", err)
+	}
+	if verifier == "" || challenge == "" {
+		t.Fatal("generatePKCE() returned an empty verifier or challenge")
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	want := base64.RawURLEncoding.EncodeToString(sum[:])
+	if challenge != want {
+		t.Fatalf("challenge %q is not the S256 digest of verifier %q", challenge, verifier)
+	}
+}
+
+func TestGeneratePKCEIsRandom(t *testing.T) {
+	v1, c1, err := generatePKCE()
+	if err != nil {
+		t.Fatalf("generatePKCE() returned error: %v", err)
+	}
+	v2, c2, err := generatePKCE()
+	if err != nil {
+		t.Fatalf("generatePKCE() returned error: %v", err)
+	}
+	if v1 == v2 || c1 == c2 {
+		t.Fatal("generatePKCE() returned the same verifier/challenge twice in a row")
+	}
+}
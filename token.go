@@ -0,0 +1,43 @@
+package uaa
+
+import (
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// notifyRefreshTokenSource wraps another oauth2.TokenSource, invoking notify
+// for the first token Token returns and then every time Token returns a token
+// whose access or refresh token differs from the one it returned last time. It is a small duplicate of the
+// reuse-and-compare logic in golang.org/x/oauth2/internal, which is
+// unexported there and so cannot otherwise be interposed on: without it there
+// is no way to observe UAA rotating a refresh token out from under a
+// long-lived TokenSource.
+type notifyRefreshTokenSource struct {
+	mu     sync.Mutex
+	base   oauth2.TokenSource
+	prev   *oauth2.Token
+	notify func(*oauth2.Token)
+}
+
+// Token returns the current token from the underlying TokenSource, calling
+// notify on the first call and whenever the token has changed since the last
+// call.
+func (s *notifyRefreshTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.prev == nil || s.prev.AccessToken != t.AccessToken || s.prev.RefreshToken != t.RefreshToken {
+		s.prev = t
+		if s.notify != nil {
+			s.notify(t)
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,80 @@
+package uaa
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+type stubTokenSource struct {
+	tokens []*oauth2.Token
+	err    error
+	calls  int
+}
+
+func (s *stubTokenSource) Token() (*oauth2.Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	t := s.tokens[s.calls]
+	if s.calls < len(s.tokens)-1 {
+		s.calls++
+	}
+	return t, nil
+}
+
+func TestNotifyRefreshTokenSourceNotifiesOnChange(t *testing.T) {
+	first := &oauth2.Token{AccessToken: "a1", RefreshToken: "r1"}
+	second := &oauth2.Token{AccessToken: "a2", RefreshToken: "r2"}
+	base := &stubTokenSource{tokens: []*oauth2.Token{first, second}}
+
+	var notified []*oauth2.Token
+	source := &notifyRefreshTokenSource{base: base, notify: func(tok *oauth2.Token) {
+		notified = append(notified, tok)
+	}}
+
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token() returned error: %v", err)
+	}
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token() returned error: %v", err)
+	}
+
+	if len(notified) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(notified))
+	}
+	if notified[0] != first || notified[1] != second {
+		t.Fatalf("notified tokens did not match the tokens returned by Token()")
+	}
+}
+
+func TestNotifyRefreshTokenSourceSkipsUnchangedToken(t *testing.T) {
+	same := &oauth2.Token{AccessToken: "a1", RefreshToken: "r1"}
+	base := &stubTokenSource{tokens: []*oauth2.Token{same, same}}
+
+	calls := 0
+	source := &notifyRefreshTokenSource{base: base, notify: func(*oauth2.Token) { calls++ }}
+
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token() returned error: %v", err)
+	}
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token() returned error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected notify to fire once for an unchanged token, got %d", calls)
+	}
+}
+
+func TestNotifyRefreshTokenSourcePropagatesError(t *testing.T) {
+	base := &stubTokenSource{err: errors.New("boom")}
+	source := &notifyRefreshTokenSource{base: base, notify: func(*oauth2.Token) {
+		t.Fatal("notify should not be called on error")
+	}}
+
+	if _, err := source.Token(); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}